@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pierrec/lz4/v4"
+)
+
+// CompressionType selects the batch compression codec used by a custom
+// socket sink configured with WithCompression.
+type CompressionType byte
+
+const (
+	// CompressionNone disables batching entirely; LogPrint writes each
+	// message to the socket as it arrives, same as before.
+	CompressionNone CompressionType = iota
+	CompressionSnappy
+	CompressionLZ4
+)
+
+const (
+	batchMaxBytes   = 64 * 1024
+	batchMaxMsgs    = 256
+	batchFlushDelay = 10 * time.Millisecond
+)
+
+// enqueueBatch appends msg's rendered record, length-prefixed, to the
+// sink's pending batch. The batch is flushed once it crosses the size/count
+// budget, or after batchFlushDelay since the first message in it, whichever
+// comes first.
+func (c *customSocketLogSystem) enqueueBatch(msg *logMessage) error {
+	record := renderRecord(msg)
+
+	c.batchMu.Lock()
+	defer c.batchMu.Unlock()
+
+	var lenPrefix [4]byte
+	binary.BigEndian.PutUint32(lenPrefix[:], uint32(len(record)))
+	c.batch.Write(lenPrefix[:])
+	c.batch.Write(record)
+	c.batchMsgs++
+
+	if c.flushTimer == nil {
+		c.flushTimer = time.AfterFunc(batchFlushDelay, c.timedFlush)
+	}
+
+	if c.batch.Len() >= batchMaxBytes || c.batchMsgs >= batchMaxMsgs {
+		return c.flushLocked()
+	}
+	return nil
+}
+
+func (c *customSocketLogSystem) timedFlush() {
+	c.batchMu.Lock()
+	defer c.batchMu.Unlock()
+	c.flushLocked()
+}
+
+// flushLocked compresses and writes out whatever is currently buffered. The
+// batch isn't cleared until the outcome is known: on failure, every record
+// coalesced into it (not just the one logPrint happened to be called with)
+// is re-queued onto the retry path via splitBatchRecords, so a single failed
+// write can't silently drop the rest of the batch. Callers must hold
+// c.batchMu.
+func (c *customSocketLogSystem) flushLocked() error {
+	if c.flushTimer != nil {
+		c.flushTimer.Stop()
+		c.flushTimer = nil
+	}
+	if c.batch.Len() == 0 {
+		return nil
+	}
+
+	raw := append([]byte(nil), c.batch.Bytes()...)
+
+	payload, err := compressBatch(c.compression, raw)
+	if err != nil {
+		c.batch.Reset()
+		c.batchMsgs = 0
+		atomic.AddUint64(&errCount, 1)
+		c.rebuffer(raw)
+		return err
+	}
+
+	envelope := make([]byte, 0, len(payload)+1)
+	envelope = append(envelope, byte(c.compression))
+	envelope = append(envelope, payload...)
+
+	c.mu.Lock()
+	err = writeFramed(c.conn, c.stream, envelope)
+	c.mu.Unlock()
+
+	c.batch.Reset()
+	c.batchMsgs = 0
+
+	if err != nil {
+		c.markUnhealthy()
+		c.rebuffer(raw)
+	}
+	return err
+}
+
+// rebuffer re-queues every length-prefixed record in raw (a batch that
+// failed to compress or send) onto the retry path, same as a single
+// message would be on a non-batched write failure.
+func (c *customSocketLogSystem) rebuffer(raw []byte) {
+	for _, record := range splitBatchRecords(raw) {
+		c.buffer(record)
+	}
+}
+
+// splitBatchRecords parses a batch built by enqueueBatch back into its
+// individual length-prefixed records.
+func splitBatchRecords(raw []byte) [][]byte {
+	var records [][]byte
+	for len(raw) >= 4 {
+		n := binary.BigEndian.Uint32(raw[:4])
+		raw = raw[4:]
+		if uint32(len(raw)) < n {
+			break
+		}
+		records = append(records, raw[:n])
+		raw = raw[n:]
+	}
+	return records
+}
+
+// Flush forces out the current partial batch. It satisfies the Flusher
+// interface so logWriter can call it, once drainTheQueue has closed the
+// queue, before the writer goroutine exits.
+func (c *customSocketLogSystem) Flush() error {
+	c.batchMu.Lock()
+	defer c.batchMu.Unlock()
+	return c.flushLocked()
+}
+
+// compressBatch compresses p with the given codec. CompressionNone returns
+// p unchanged.
+func compressBatch(t CompressionType, p []byte) ([]byte, error) {
+	switch t {
+	case CompressionSnappy:
+		return snappy.Encode(nil, p), nil
+	case CompressionLZ4:
+		var buf bytes.Buffer
+		w := lz4.NewWriter(&buf)
+		if _, err := w.Write(p); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return p, nil
+	}
+}
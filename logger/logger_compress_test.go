@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/pierrec/lz4/v4"
+)
+
+func TestCompressBatchNoneIsUnchanged(t *testing.T) {
+	p := []byte("hello world")
+	got, err := compressBatch(CompressionNone, p)
+	if err != nil {
+		t.Fatalf("compressBatch: %v", err)
+	}
+	if !bytes.Equal(got, p) {
+		t.Errorf("compressBatch(CompressionNone) = %q, want %q", got, p)
+	}
+}
+
+func TestCompressBatchSnappyRoundTrips(t *testing.T) {
+	p := []byte("hello world, compressed")
+	got, err := compressBatch(CompressionSnappy, p)
+	if err != nil {
+		t.Fatalf("compressBatch: %v", err)
+	}
+	decoded, err := snappy.Decode(nil, got)
+	if err != nil {
+		t.Fatalf("snappy.Decode: %v", err)
+	}
+	if !bytes.Equal(decoded, p) {
+		t.Errorf("round trip = %q, want %q", decoded, p)
+	}
+}
+
+func TestCompressBatchLZ4RoundTrips(t *testing.T) {
+	p := []byte("hello world, compressed differently")
+	got, err := compressBatch(CompressionLZ4, p)
+	if err != nil {
+		t.Fatalf("compressBatch: %v", err)
+	}
+	r := lz4.NewReader(bytes.NewReader(got))
+	decoded := make([]byte, len(p))
+	if _, err := r.Read(decoded); err != nil {
+		t.Fatalf("lz4 read: %v", err)
+	}
+	if !bytes.Equal(decoded, p) {
+		t.Errorf("round trip = %q, want %q", decoded, p)
+	}
+}
+
+func TestSplitBatchRecordsRoundTrips(t *testing.T) {
+	records := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+
+	var batch bytes.Buffer
+	for _, r := range records {
+		var lenPrefix [4]byte
+		binary.BigEndian.PutUint32(lenPrefix[:], uint32(len(r)))
+		batch.Write(lenPrefix[:])
+		batch.Write(r)
+	}
+
+	got := splitBatchRecords(batch.Bytes())
+	if len(got) != len(records) {
+		t.Fatalf("splitBatchRecords returned %d records, want %d", len(got), len(records))
+	}
+	for i := range records {
+		if !bytes.Equal(got[i], records[i]) {
+			t.Errorf("record %d = %q, want %q", i, got[i], records[i])
+		}
+	}
+}
+
+func TestSplitBatchRecordsIgnoresTrailingGarbage(t *testing.T) {
+	var batch bytes.Buffer
+	var lenPrefix [4]byte
+	binary.BigEndian.PutUint32(lenPrefix[:], 3)
+	batch.Write(lenPrefix[:])
+	batch.WriteString("abc")
+	batch.Write([]byte{1, 2}) // short trailing length prefix, not a full record
+
+	got := splitBatchRecords(batch.Bytes())
+	if len(got) != 1 || string(got[0]) != "abc" {
+		t.Fatalf("splitBatchRecords = %v, want [\"abc\"]", got)
+	}
+}
@@ -0,0 +1,461 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogSystem is a single destination for rendered log messages. Multiple
+// LogSystems may be registered at once via AddLogSystem (for example local
+// stderr plus remote syslog plus an in-memory buffer for tests), each with
+// its own level threshold, and logWriter fans every dequeued message out to
+// whichever of them are willing to accept it. This mirrors the dispatch
+// loop used by go-ethereum's logger package.
+type LogSystem interface {
+	// GetLogLevel returns the minimum severity this sink currently accepts.
+	GetLogLevel() Level
+	// SetLogLevel changes the minimum severity this sink accepts.
+	SetLogLevel(lvl Level)
+	// LogPrint writes a single already-rendered message at the given level.
+	LogPrint(lvl Level, p []byte) error
+}
+
+var (
+	logSystemsMu sync.RWMutex
+	logSystems   []LogSystem
+)
+
+// AddLogSystem registers an additional sink that future messages will be
+// fanned out to, on top of whatever sinks are already registered.
+func AddLogSystem(sys LogSystem) {
+	logSystemsMu.Lock()
+	logSystems = append(logSystems, sys)
+	logSystemsMu.Unlock()
+}
+
+// Flusher is implemented by LogSystems that buffer messages internally (for
+// example the batching custom socket sink) and need a chance to push out a
+// final partial batch before the writer goroutine exits.
+type Flusher interface {
+	Flush() error
+}
+
+// flushLogSystems calls Flush on every registered sink that implements
+// Flusher. It is invoked by logWriter on shutdown, once drainTheQueue has
+// closed the queue and before the custom socket is closed.
+func flushLogSystems() {
+	logSystemsMu.RLock()
+	defer logSystemsMu.RUnlock()
+
+	for _, sys := range logSystems {
+		if f, ok := sys.(Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+// Reset discards every registered LogSystem, including the built-in syslog
+// sink installed at init time. Callers that still want syslog output must
+// re-register it explicitly. This is primarily useful in tests that want a
+// clean slate before installing a ring buffer sink.
+func Reset() {
+	logSystemsMu.Lock()
+	logSystems = nil
+	logSystemsMu.Unlock()
+}
+
+// Flush blocks until the pending message queue has drained or ctx is done,
+// whichever happens first.
+func Flush(ctx context.Context) error {
+	for len(messages) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// levelEnabled reports whether a message at msg severity should be
+// delivered to a sink whose threshold is sink. It reuses the existing
+// levelSysLog priority mapping so sink thresholds compare the same way
+// syslog priorities do: a message is delivered if it is at least as severe
+// (numerically <=) as the configured threshold.
+func levelEnabled(sink, msg Level) bool {
+	return levelSysLog[msg] <= levelSysLog[sink]
+}
+
+// dispatch fans a dequeued message out to every registered sink that wants
+// it at its current level.
+func dispatch(msg *logMessage) {
+	logSystemsMu.RLock()
+	defer logSystemsMu.RUnlock()
+
+	if len(logSystems) == 0 {
+		// No sinks registered (e.g. after a Reset with nothing re-added):
+		// fall back to the historical behavior of writing directly to
+		// syslog or the custom socket. Going through customSocketSink
+		// rather than a cached conn means this keeps working across
+		// reconnects.
+		if customSocketSink == nil {
+			write(msg)
+		} else {
+			conn, stream := customSocketSink.currentConn()
+			writeCustomSocket(conn, stream, msg)
+		}
+		return
+	}
+
+	fields := msg.fields()
+
+	for _, sys := range logSystems {
+		if !levelEnabled(sys.GetLogLevel(), msg.level) {
+			continue
+		}
+
+		var err error
+		if structured, ok := sys.(StructuredLogSystem); ok && len(fields) > 0 {
+			err = structured.LogPrintFields(msg.level, msg.Bytes(), fields)
+		} else {
+			err = sys.LogPrint(msg.level, flatten(msg.Bytes(), fields))
+		}
+		if err != nil {
+			atomic.AddUint64(&errCount, 1)
+		}
+	}
+}
+
+// flatten appends fields as "k=v k=v" to p for sinks with no native
+// structured rendering.
+func flatten(p []byte, fields []Field) []byte {
+	if len(fields) == 0 {
+		return p
+	}
+
+	out := make([]byte, 0, len(p)+len(fields)*16)
+	out = append(out, p...)
+	for _, f := range fields {
+		out = append(out, ' ')
+		out = append(out, f.Key...)
+		out = append(out, '=')
+		out = append(out, fmt.Sprint(f.Value)...)
+	}
+	return out
+}
+
+// syslogLogSystem is the cgo syslog sink. It is registered by default so
+// existing callers keep working unchanged.
+type syslogLogSystem struct {
+	mu    sync.Mutex
+	level Level
+}
+
+func newSyslogLogSystem() *syslogLogSystem {
+	return &syslogLogSystem{level: Levels.Debug}
+}
+
+func (s *syslogLogSystem) GetLogLevel() Level {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.level
+}
+
+func (s *syslogLogSystem) SetLogLevel(lvl Level) {
+	s.mu.Lock()
+	s.level = lvl
+	s.mu.Unlock()
+}
+
+func (s *syslogLogSystem) LogPrint(lvl Level, p []byte) error {
+	msg := &logMessage{level: lvl}
+	msg.Write(p)
+	return write(msg)
+}
+
+// customSocketLogSystem wraps a net.Conn sink, i.e. the connection
+// established by SetCustomSocket/SetCustomSocketTLS. It is a supervised
+// component: write failures trigger a reconnect with backoff, and messages
+// that arrive while the socket is down are buffered (and, if that buffer
+// overflows, spilled to disk) rather than dropped outright.
+type customSocketLogSystem struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool
+	level  Level
+
+	// redial parameters, needed to recreate conn after a write failure.
+	network   string
+	address   string
+	tlsConfig *tls.Config
+
+	healthMu sync.RWMutex
+	healthy  bool
+
+	// secondary queue buffered while the socket is down, separate from the
+	// main messages channel so fresh logs aren't dropped under a transient
+	// network blip.
+	retryMu       sync.Mutex
+	retry         [][]byte
+	retryBytes    int
+	retryMaxMsgs  int
+	retryMaxBytes int
+
+	spillDir   string
+	spillMu    sync.Mutex
+	spillFile  *os.File
+	spillIdx   int
+	spillBytes int
+
+	dropped    uint64
+	reconnects uint64
+
+	// batching/compression state; unused (batchMu never contended) unless
+	// compression is something other than CompressionNone.
+	compression CompressionType
+	batchMu     sync.Mutex
+	batch       bytes.Buffer
+	batchMsgs   int
+	flushTimer  *time.Timer
+}
+
+// CustomSocketOption configures optional behavior for a custom socket sink,
+// set up via SetCustomSocket/SetCustomSocketTLS.
+type CustomSocketOption func(*customSocketLogSystem)
+
+// WithCompression enables batched, compressed delivery: up to a size/count
+// budget of messages are coalesced and flushed together, either once the
+// budget is hit or after a short deadline, instead of one write per
+// message.
+func WithCompression(t CompressionType) CustomSocketOption {
+	return func(c *customSocketLogSystem) {
+		c.compression = t
+	}
+}
+
+// WithRetryBudget bounds the secondary in-memory queue used to hold
+// messages while the custom socket is reconnecting. Once either limit would
+// be exceeded, further messages spill to disk (if WithSpillDir was given) or
+// are dropped.
+func WithRetryBudget(maxMsgs, maxBytes int) CustomSocketOption {
+	return func(c *customSocketLogSystem) {
+		c.retryMaxMsgs = maxMsgs
+		c.retryMaxBytes = maxBytes
+	}
+}
+
+// WithSpillDir enables on-disk overflow: messages that don't fit the retry
+// budget are appended to a rotating segment file in dir instead of being
+// dropped, and replayed once the socket reconnects.
+func WithSpillDir(dir string) CustomSocketOption {
+	return func(c *customSocketLogSystem) {
+		c.spillDir = dir
+	}
+}
+
+func newCustomSocketLogSystem(conn net.Conn, stream bool, network, address string, tlsConfig *tls.Config, opts ...CustomSocketOption) *customSocketLogSystem {
+	c := &customSocketLogSystem{
+		conn:          conn,
+		stream:        stream,
+		level:         Levels.Debug,
+		network:       network,
+		address:       address,
+		tlsConfig:     tlsConfig,
+		healthy:       true,
+		retryMaxMsgs:  defaultRetryMaxMsgs,
+		retryMaxBytes: defaultRetryMaxBytes,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *customSocketLogSystem) GetLogLevel() Level {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.level
+}
+
+func (c *customSocketLogSystem) SetLogLevel(lvl Level) {
+	c.mu.Lock()
+	c.level = lvl
+	c.mu.Unlock()
+}
+
+func (c *customSocketLogSystem) LogPrint(lvl Level, p []byte) error {
+	return c.logPrint(lvl, p, nil)
+}
+
+// LogPrintFields satisfies StructuredLogSystem: fields are rendered inside
+// the record's RFC 5424 STRUCTURED-DATA rather than flattened into MSG.
+func (c *customSocketLogSystem) LogPrintFields(lvl Level, p []byte, fields []Field) error {
+	return c.logPrint(lvl, p, fields)
+}
+
+func (c *customSocketLogSystem) logPrint(lvl Level, p []byte, fields []Field) error {
+	msg := &logMessage{level: lvl}
+	msg.Write(p)
+	for _, f := range fields {
+		msg.addField(f)
+	}
+
+	if !c.isHealthy() {
+		c.buffer(renderRecord(msg))
+		return nil
+	}
+
+	if c.compression == CompressionNone {
+		c.mu.Lock()
+		err := writeCustomSocket(c.conn, c.stream, msg)
+		c.mu.Unlock()
+		if err != nil {
+			c.markUnhealthy()
+			c.buffer(renderRecord(msg))
+		}
+		return err
+	}
+
+	// enqueueBatch only returns an error once it has flushed (the batch
+	// crossed its size/count budget), and flushLocked already rebuffers
+	// every coalesced record itself on failure, so there's nothing left
+	// to buffer here.
+	return c.enqueueBatch(msg)
+}
+
+// currentConn returns the sink's live connection and stream flag. Callers
+// that need to write outside of logPrint (e.g. dispatch's no-sinks
+// fallback) should go through this rather than capturing conn once, since
+// reconnectLoop swaps c.conn out from under a dead connection.
+func (c *customSocketLogSystem) currentConn() (net.Conn, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn, c.stream
+}
+
+// Close closes the sink's current connection. It's called on shutdown,
+// after the writer goroutine has drained the message queue.
+func (c *customSocketLogSystem) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Stats reports this sink's current retry queue depth, lifetime dropped
+// message count, and lifetime reconnect count, so operators can alert on
+// them.
+func (c *customSocketLogSystem) Stats() Stats {
+	c.retryMu.Lock()
+	depth := len(c.retry)
+	c.retryMu.Unlock()
+
+	return Stats{
+		QueueDepth:      depth,
+		DroppedMessages: atomic.LoadUint64(&c.dropped),
+		ReconnectCount:  atomic.LoadUint64(&c.reconnects),
+	}
+}
+
+// writerLogSystem adapts any io.Writer (including *os.File) into a
+// LogSystem. It writes each message as given; the level prefix is already
+// part of a message queued through queueMsg, so none is added here.
+type writerLogSystem struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// NewWriterLogSystem wraps w (e.g. os.Stderr, a bytes.Buffer, or any other
+// io.Writer) as a LogSystem.
+func NewWriterLogSystem(w io.Writer) LogSystem {
+	return &writerLogSystem{w: w, level: Levels.Debug}
+}
+
+// NewFileLogSystem wraps an *os.File as a LogSystem. It is a thin
+// convenience over NewWriterLogSystem for the common case of logging to a
+// file on disk.
+func NewFileLogSystem(f *os.File) LogSystem {
+	return NewWriterLogSystem(f)
+}
+
+func (w *writerLogSystem) GetLogLevel() Level {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.level
+}
+
+func (w *writerLogSystem) SetLogLevel(lvl Level) {
+	w.mu.Lock()
+	w.level = lvl
+	w.mu.Unlock()
+}
+
+func (w *writerLogSystem) LogPrint(lvl Level, p []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.w.Write(p)
+	return err
+}
+
+// ringLogSystem is an in-memory, fixed-size LogSystem useful for tests that
+// want to assert on what was logged without standing up syslog or a socket.
+type ringLogSystem struct {
+	mu     sync.Mutex
+	level  Level
+	buf    [][]byte
+	size   int
+	cursor int
+}
+
+// NewRingLogSystem creates a LogSystem that retains only the last size
+// messages it was given, overwriting the oldest once full.
+func NewRingLogSystem(size int) *ringLogSystem {
+	return &ringLogSystem{level: Levels.Debug, size: size}
+}
+
+func (r *ringLogSystem) GetLogLevel() Level {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.level
+}
+
+func (r *ringLogSystem) SetLogLevel(lvl Level) {
+	r.mu.Lock()
+	r.level = lvl
+	r.mu.Unlock()
+}
+
+func (r *ringLogSystem) LogPrint(lvl Level, p []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line := append([]byte{}, p...)
+	if len(r.buf) < r.size {
+		r.buf = append(r.buf, line)
+	} else {
+		r.buf[r.cursor] = line
+		r.cursor = (r.cursor + 1) % r.size
+	}
+	return nil
+}
+
+// Messages returns a snapshot of the retained messages, oldest first.
+func (r *ringLogSystem) Messages() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([][]byte, len(r.buf))
+	for i := range r.buf {
+		out[i] = r.buf[(r.cursor+i)%len(r.buf)]
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlatten(t *testing.T) {
+	cases := []struct {
+		name   string
+		p      []byte
+		fields []Field
+		want   string
+	}{
+		{"no fields", []byte("hello"), nil, "hello"},
+		{"one field", []byte("hello"), []Field{{Key: "k", Value: "v"}}, "hello k=v"},
+		{
+			"multiple fields",
+			[]byte("hello"),
+			[]Field{{Key: "a", Value: 1}, {Key: "b", Value: "two"}},
+			"hello a=1 b=two",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := string(flatten(c.p, c.fields))
+			if got != c.want {
+				t.Errorf("flatten(%q, %v) = %q, want %q", c.p, c.fields, got, c.want)
+			}
+		})
+	}
+}
+
+func TestLevelEnabled(t *testing.T) {
+	// A sink thresholded at Info should accept Info and Error but reject
+	// Debug, since Debug is less severe.
+	if !levelEnabled(Levels.Info, Levels.Info) {
+		t.Error("expected Info message enabled for Info sink")
+	}
+	if !levelEnabled(Levels.Info, Levels.Error) {
+		t.Error("expected Error message enabled for Info sink")
+	}
+	if levelEnabled(Levels.Info, Levels.Debug) {
+		t.Error("expected Debug message disabled for Info sink")
+	}
+}
+
+func TestRingLogSystemWraps(t *testing.T) {
+	r := NewRingLogSystem(2)
+
+	for _, s := range []string{"one", "two", "three"} {
+		if err := r.LogPrint(Levels.Info, []byte(s)); err != nil {
+			t.Fatalf("LogPrint(%q): %v", s, err)
+		}
+	}
+
+	got := r.Messages()
+	want := [][]byte{[]byte("two"), []byte("three")}
+	if len(got) != len(want) {
+		t.Fatalf("Messages() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("Messages()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDispatchFansOutToRegisteredSinks(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	ring := NewRingLogSystem(4)
+	ring.SetLogLevel(Levels.Info)
+	AddLogSystem(ring)
+
+	msg := &logMessage{level: Levels.Info}
+	msg.Write([]byte("hi"))
+	dispatch(msg)
+
+	got := ring.Messages()
+	if len(got) != 1 || string(got[0]) != "hi" {
+		t.Fatalf("Messages() = %v, want [\"hi\"]", got)
+	}
+
+	debugMsg := &logMessage{level: Levels.Debug}
+	debugMsg.Write([]byte("too quiet"))
+	dispatch(debugMsg)
+
+	if got := ring.Messages(); len(got) != 1 {
+		t.Fatalf("Messages() after a below-threshold message = %v, want unchanged", got)
+	}
+}
+
+// TestCustomSocketCurrentConnReflectsReconnect guards against stale-conn
+// readers: anything reading the sink's connection (e.g. dispatch's no-sinks
+// fallback) must see a reconnect's new conn, not the one captured at setup.
+func TestCustomSocketCurrentConnReflectsReconnect(t *testing.T) {
+	c := &customSocketLogSystem{conn: &fakeConn{}}
+
+	newConn := &fakeConn{}
+	c.mu.Lock()
+	c.conn = newConn
+	c.stream = true
+	c.mu.Unlock()
+
+	conn, stream := c.currentConn()
+	if conn != net.Conn(newConn) || !stream {
+		t.Errorf("currentConn() = (%v, %v), want (%v, true)", conn, stream, newConn)
+	}
+}
+
+// chanWriter is an io.Writer that hands each write off on a channel, so a
+// test can synchronize with logWriter's background goroutine instead of
+// polling or racing on a shared buffer.
+type chanWriter chan []byte
+
+func (c chanWriter) Write(p []byte) (int, error) {
+	c <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+// TestWriterLogSystemDoesNotDoublePrefix drives a real message through the
+// normal queueMsg/dispatch path (which already bakes levelMapFmt into the
+// body) and checks writerLogSystem.LogPrint doesn't prepend it a second
+// time.
+func TestWriterLogSystemDoesNotDoublePrefix(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	out := make(chanWriter, 1)
+	AddLogSystem(NewWriterLogSystem(out))
+
+	if err := queueMsg(Levels.Info, "", "hello"); err != nil {
+		t.Fatalf("queueMsg: %v", err)
+	}
+
+	select {
+	case got := <-out:
+		if n := strings.Count(string(got), "[Info]"); n != 1 {
+			t.Errorf("writer sink wrote %q, want exactly one [Info] prefix, got %d", got, n)
+		}
+		if !strings.Contains(string(got), "hello") {
+			t.Errorf("writer sink wrote %q, want it to contain %q", got, "hello")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message to reach the writer sink")
+	}
+}
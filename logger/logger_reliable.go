@@ -0,0 +1,265 @@
+package logger
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	mathrand "math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultRetryMaxMsgs  = 4096
+	defaultRetryMaxBytes = 4 * 1024 * 1024
+
+	spillSegmentMaxBytes = 8 * 1024 * 1024
+
+	redialMinDelay = 250 * time.Millisecond
+	redialMaxDelay = 30 * time.Second
+)
+
+// Stats reports health and backpressure information for a reliable custom
+// socket sink, so operators can alert on it.
+type Stats struct {
+	// QueueDepth is the number of messages currently buffered in the
+	// in-memory retry queue (i.e. not yet delivered or spilled to disk).
+	QueueDepth int
+	// DroppedMessages is the lifetime count of messages discarded because
+	// neither the retry budget nor (if configured) disk spill had room.
+	DroppedMessages uint64
+	// ReconnectCount is the lifetime count of successful reconnects.
+	ReconnectCount uint64
+}
+
+// CustomSocketStats reports Stats for the most recently configured custom
+// socket sink. It returns the zero Stats if no custom socket is configured.
+func CustomSocketStats() Stats {
+	if customSocketSink == nil {
+		return Stats{}
+	}
+	return customSocketSink.Stats()
+}
+
+var customSocketSink *customSocketLogSystem
+
+func (c *customSocketLogSystem) isHealthy() bool {
+	c.healthMu.RLock()
+	defer c.healthMu.RUnlock()
+	return c.healthy
+}
+
+// markUnhealthy flags the sink as down and, if it wasn't already, starts a
+// reconnect loop. Called after a write failure.
+func (c *customSocketLogSystem) markUnhealthy() {
+	c.healthMu.Lock()
+	wasHealthy := c.healthy
+	c.healthy = false
+	c.healthMu.Unlock()
+
+	if wasHealthy {
+		go c.reconnectLoop()
+	}
+}
+
+// reconnectLoop redials with exponential backoff and jitter until it
+// succeeds or the process is shutting down, then replays whatever was
+// buffered while the socket was down.
+func (c *customSocketLogSystem) reconnectLoop() {
+	delay := redialMinDelay
+
+	for {
+		shuttingDownMux.RLock()
+		down := shuttingDown
+		shuttingDownMux.RUnlock()
+		if down {
+			return
+		}
+
+		var conn net.Conn
+		var err error
+		if c.tlsConfig != nil {
+			conn, err = tls.Dial("tcp", c.address, c.tlsConfig)
+		} else {
+			conn, err = net.Dial(c.network, c.address)
+		}
+		if err != nil {
+			atomic.AddUint64(&errCount, 1)
+
+			jitter := time.Duration(mathrand.Int63n(int64(delay)/2 + 1))
+			time.Sleep(delay + jitter)
+
+			delay *= 2
+			if delay > redialMaxDelay {
+				delay = redialMaxDelay
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		old := c.conn
+		c.conn = conn
+		c.mu.Unlock()
+		if old != nil {
+			old.Close()
+		}
+
+		atomic.AddUint64(&c.reconnects, 1)
+
+		c.healthMu.Lock()
+		c.healthy = true
+		c.healthMu.Unlock()
+
+		c.replay()
+		return
+	}
+}
+
+// buffer holds a rendered record in the bounded retry queue while the
+// socket is down, spilling to disk (or dropping, if no spill dir is
+// configured) once the budget is exhausted.
+func (c *customSocketLogSystem) buffer(record []byte) {
+	c.retryMu.Lock()
+	if len(c.retry) < c.retryMaxMsgs && c.retryBytes+len(record) <= c.retryMaxBytes {
+		c.retry = append(c.retry, record)
+		c.retryBytes += len(record)
+		c.retryMu.Unlock()
+		return
+	}
+	c.retryMu.Unlock()
+
+	c.spill(record)
+}
+
+// spill appends record to the current on-disk segment file, length-prefixed
+// so it can be read back frame by frame, rolling over to a new segment (and
+// fsyncing the old one) once spillSegmentMaxBytes is reached.
+func (c *customSocketLogSystem) spill(record []byte) {
+	if c.spillDir == "" {
+		atomic.AddUint64(&c.dropped, 1)
+		return
+	}
+
+	c.spillMu.Lock()
+	defer c.spillMu.Unlock()
+
+	if c.spillFile == nil {
+		c.openSpillSegmentLocked()
+		if c.spillFile == nil {
+			atomic.AddUint64(&c.dropped, 1)
+			return
+		}
+	}
+
+	var lenPrefix [4]byte
+	binary.BigEndian.PutUint32(lenPrefix[:], uint32(len(record)))
+	if _, err := c.spillFile.Write(lenPrefix[:]); err != nil {
+		atomic.AddUint64(&errCount, 1)
+	}
+	if _, err := c.spillFile.Write(record); err != nil {
+		atomic.AddUint64(&errCount, 1)
+	}
+	c.spillBytes += len(lenPrefix) + len(record)
+
+	if c.spillBytes >= spillSegmentMaxBytes {
+		c.spillIdx++
+		c.rollSpillSegmentLocked()
+	}
+}
+
+func (c *customSocketLogSystem) segmentPath(idx int) string {
+	return filepath.Join(c.spillDir, fmt.Sprintf("golog-spill-%06d.seg", idx))
+}
+
+func (c *customSocketLogSystem) openSpillSegmentLocked() {
+	f, err := os.Create(c.segmentPath(c.spillIdx))
+	if err != nil {
+		atomic.AddUint64(&errCount, 1)
+		return
+	}
+	c.spillFile = f
+	c.spillBytes = 0
+}
+
+// rollSpillSegmentLocked fsyncs and closes the current segment (fsync only
+// happens on rollover, not per write) before opening the next one.
+func (c *customSocketLogSystem) rollSpillSegmentLocked() {
+	if c.spillFile != nil {
+		c.spillFile.Sync()
+		c.spillFile.Close()
+		c.spillFile = nil
+	}
+	c.openSpillSegmentLocked()
+}
+
+// replay drains the in-memory retry queue and any spilled segment files
+// back out over the (now healthy) connection, oldest first.
+func (c *customSocketLogSystem) replay() {
+	c.retryMu.Lock()
+	pending := c.retry
+	c.retry = nil
+	c.retryBytes = 0
+	c.retryMu.Unlock()
+
+	for _, record := range pending {
+		c.writeRecord(record)
+	}
+
+	c.spillMu.Lock()
+	if c.spillFile != nil {
+		c.spillFile.Sync()
+		c.spillFile.Close()
+		c.spillFile = nil
+	}
+	spillDir := c.spillDir
+	c.spillIdx = 0
+	c.spillBytes = 0
+	c.spillMu.Unlock()
+
+	if spillDir == "" {
+		return
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(spillDir, "golog-spill-*.seg"))
+	sort.Strings(segments)
+	for _, path := range segments {
+		c.replaySegment(path)
+		os.Remove(path)
+	}
+}
+
+func (c *customSocketLogSystem) replaySegment(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		atomic.AddUint64(&errCount, 1)
+		return
+	}
+	defer f.Close()
+
+	var lenPrefix [4]byte
+	for {
+		if _, err := f.Read(lenPrefix[:]); err != nil {
+			return
+		}
+		record := make([]byte, binary.BigEndian.Uint32(lenPrefix[:]))
+		if _, err := f.Read(record); err != nil {
+			return
+		}
+		c.writeRecord(record)
+	}
+}
+
+// writeRecord writes an already-rendered record straight to the wire,
+// bypassing compression/batching, which is appropriate for replayed and
+// buffered records since they're delivered individually on reconnect.
+func (c *customSocketLogSystem) writeRecord(record []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeFramed(c.conn, c.stream, record); err != nil {
+		atomic.AddUint64(&errCount, 1)
+	}
+}
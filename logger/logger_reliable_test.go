@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// closeTrackingConn wraps fakeConn to additionally count Close calls, so
+// tests can assert a stale connection was actually closed rather than just
+// dropped.
+type closeTrackingConn struct {
+	fakeConn
+	closed int32
+}
+
+func (c *closeTrackingConn) Close() error {
+	atomic.AddInt32(&c.closed, 1)
+	return nil
+}
+
+func TestBufferDropsWhenNoSpillDirConfigured(t *testing.T) {
+	c := &customSocketLogSystem{retryMaxMsgs: 1, retryMaxBytes: 1024}
+
+	c.buffer([]byte("first"))
+	c.buffer([]byte("second")) // budget exceeded, no spill dir configured
+
+	if len(c.retry) != 1 {
+		t.Fatalf("retry queue = %d entries, want 1", len(c.retry))
+	}
+	if got := atomic.LoadUint64(&c.dropped); got != 1 {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+}
+
+func TestStatsReportsDroppedAndQueueDepth(t *testing.T) {
+	c := &customSocketLogSystem{retryMaxMsgs: 1, retryMaxBytes: 1024}
+	c.buffer([]byte("kept"))
+	c.buffer([]byte("dropped"))
+
+	stats := c.Stats()
+	if stats.QueueDepth != 1 {
+		t.Errorf("QueueDepth = %d, want 1", stats.QueueDepth)
+	}
+	if stats.DroppedMessages != 1 {
+		t.Errorf("DroppedMessages = %d, want 1", stats.DroppedMessages)
+	}
+}
+
+func TestSpillAndReplayRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	conn := &fakeConn{}
+	c := &customSocketLogSystem{
+		spillDir: dir,
+		conn:     conn,
+		stream:   false,
+	}
+
+	c.spill([]byte("first"))
+	c.spill([]byte("second"))
+
+	c.replay()
+
+	want := "firstsecond"
+	if string(conn.written) != want {
+		t.Errorf("replay wrote %q, want %q", conn.written, want)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "golog-spill-*.seg"))
+	if len(segments) != 0 {
+		t.Errorf("replay left %d segment files behind, want 0", len(segments))
+	}
+}
+
+// TestReconnectLoopClosesStaleConn guards against leaking a socket/fd on
+// every reconnect cycle: the connection that just failed must be closed
+// once reconnectLoop has dialed its replacement, not merely dropped.
+func TestReconnectLoopClosesStaleConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	old := &closeTrackingConn{}
+	c := &customSocketLogSystem{
+		conn:    old,
+		network: "tcp",
+		address: ln.Addr().String(),
+	}
+
+	c.reconnectLoop()
+
+	if got := atomic.LoadInt32(&old.closed); got != 1 {
+		t.Errorf("old conn Close() called %d times, want 1", got)
+	}
+	if c.conn == net.Conn(old) {
+		t.Error("c.conn still points at the old, stale connection after reconnect")
+	}
+}
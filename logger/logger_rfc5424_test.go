@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"net"
+	"testing"
+)
+
+func TestStructuredDataNilWhenNoFields(t *testing.T) {
+	if got := structuredData(nil); got != "-" {
+		t.Errorf("structuredData(nil) = %q, want \"-\"", got)
+	}
+}
+
+func TestStructuredDataRendersFields(t *testing.T) {
+	fields := []Field{{Key: "user", Value: "alice"}, {Key: "count", Value: 3}}
+	got := structuredData(fields)
+	want := `[fields@32473 user="alice" count="3"]`
+	if got != want {
+		t.Errorf("structuredData(%v) = %q, want %q", fields, got, want)
+	}
+}
+
+func TestEscapeSDParam(t *testing.T) {
+	got := escapeSDParam(`back\slash "quoted" [bracket]`)
+	want := `back\\slash \"quoted\" [bracket\]`
+	if got != want {
+		t.Errorf("escapeSDParam = %q, want %q", got, want)
+	}
+}
+
+// fakeConn is a minimal net.Conn that records whatever is written to it.
+type fakeConn struct {
+	net.Conn
+	written []byte
+}
+
+func (f *fakeConn) Write(p []byte) (int, error) {
+	f.written = append(f.written, p...)
+	return len(p), nil
+}
+
+func TestWriteFramedStreamUsesOctetCounting(t *testing.T) {
+	conn := &fakeConn{}
+	body := []byte("<14>1 a record")
+	if err := writeFramed(conn, true, body); err != nil {
+		t.Fatalf("writeFramed: %v", err)
+	}
+
+	want := "14 <14>1 a record"
+	if string(conn.written) != want {
+		t.Errorf("writeFramed(stream) wrote %q, want %q", conn.written, want)
+	}
+}
+
+func TestWriteFramedDatagramIsUnframed(t *testing.T) {
+	conn := &fakeConn{}
+	body := []byte("<14>1 a record")
+	if err := writeFramed(conn, false, body); err != nil {
+		t.Fatalf("writeFramed: %v", err)
+	}
+
+	if string(conn.written) != string(body) {
+		t.Errorf("writeFramed(datagram) wrote %q, want %q", conn.written, body)
+	}
+}
@@ -0,0 +1,230 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Field is a single structured key-value pair attached to a log message via
+// Infow or With.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// parseFields turns an alternating key, value, key, value... list into
+// Fields. A trailing key with no value is dropped.
+func parseFields(kv []interface{}) []Field {
+	fields := make([]Field, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, _ := kv[i].(string)
+		fields = append(fields, Field{Key: key, Value: kv[i+1]})
+	}
+	return fields
+}
+
+// addField appends f to the message, keeping up to len(fieldsInline) inline
+// to avoid an allocation for the common case.
+func (m *logMessage) addField(f Field) {
+	if m.fieldsLen < len(m.fieldsInline) {
+		m.fieldsInline[m.fieldsLen] = f
+		m.fieldsLen++
+		return
+	}
+	m.fieldsExtra = append(m.fieldsExtra, f)
+}
+
+// fields returns every field attached to the message, inline ones first.
+func (m *logMessage) fields() []Field {
+	if m.fieldsLen == 0 {
+		return nil
+	}
+	if len(m.fieldsExtra) == 0 {
+		return m.fieldsInline[:m.fieldsLen]
+	}
+	out := make([]Field, 0, m.fieldsLen+len(m.fieldsExtra))
+	out = append(out, m.fieldsInline[:m.fieldsLen]...)
+	out = append(out, m.fieldsExtra...)
+	return out
+}
+
+// resetFields clears a reused message's fields. Called alongside the
+// existing bytes.Buffer Reset() in logWriter's pool reuse path so a pooled
+// message doesn't leak a prior caller's field references: logMessages live
+// forever in the fixed freeMessages pool, so truncating length alone would
+// leave every Field.Value reachable from its slot until next overwritten.
+func (m *logMessage) resetFields() {
+	for i := 0; i < m.fieldsLen; i++ {
+		m.fieldsInline[i] = Field{}
+	}
+	m.fieldsLen = 0
+
+	for i := range m.fieldsExtra {
+		m.fieldsExtra[i] = Field{}
+	}
+	m.fieldsExtra = m.fieldsExtra[:0]
+}
+
+// StructuredLogSystem is implemented by sinks that can render a message's
+// structured fields natively (RFC 5424 STRUCTURED-DATA, a JSON object, ...)
+// instead of having them flattened into the message text. dispatch prefers
+// this over LogPrint whenever a message carries fields.
+type StructuredLogSystem interface {
+	LogSystem
+	LogPrintFields(lvl Level, p []byte, fields []Field) error
+}
+
+// structuredDataID is the RFC 5424 SD-ID under which fields are nested by
+// the custom socket sink's STRUCTURED-DATA.
+var structuredDataID = "fields@32473"
+
+// SetStructuredDataID overrides the RFC 5424 SD-ID used for STRUCTURED-DATA
+// on custom socket sinks. The default is "fields@32473".
+func SetStructuredDataID(id string) {
+	if id == "" {
+		return
+	}
+	structuredDataID = id
+}
+
+// Logger is a thin handle carrying a prefix and a set of structured fields
+// that get attached to every message logged through it. The printf-style
+// package functions remain a thin wrapper on top of the same queueing path,
+// so existing callers are unaffected.
+type Logger struct {
+	prefix string
+	fields []Field
+}
+
+// With returns a Logger that attaches kv (alternating key, value pairs) to
+// every message it logs.
+func With(kv ...interface{}) *Logger {
+	return &Logger{fields: parseFields(kv)}
+}
+
+// With returns a copy of l with kv merged into its existing fields.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	merged := make([]Field, 0, len(l.fields)+len(kv)/2)
+	merged = append(merged, l.fields...)
+	merged = append(merged, parseFields(kv)...)
+	return &Logger{prefix: l.prefix, fields: merged}
+}
+
+// Infow logs msg at Info level together with l's fields and any additional
+// kv pairs.
+func (l *Logger) Infow(msg string, kv ...interface{}) {
+	infow(l.prefix, l.fields, msg, kv)
+}
+
+// Infow logs msg at Info level with the given kv (alternating key, value
+// pairs) as structured fields.
+func Infow(msg string, kv ...interface{}) {
+	infow("", nil, msg, kv)
+}
+
+func infow(prefix string, base []Field, msg string, kv []interface{}) {
+	fields := base
+	if extra := parseFields(kv); len(extra) > 0 {
+		fields = make([]Field, 0, len(base)+len(extra))
+		fields = append(fields, base...)
+		fields = append(fields, extra...)
+	}
+
+	logMsg := fmt.Sprintf("%s %s %s", levelMapFmt[Levels.Info], prefix, msg)
+	queueMsgDirect(Levels.Info, logMsg, fields...)
+}
+
+// jsonLogSystem emits one JSON object per line:
+// {"ts":..,"level":..,"msg":..,"fields":{..}}.
+type jsonLogSystem struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// NewJSONLogSystem wraps w as a LogSystem that renders each message as a
+// single JSON object per line, with structured fields nested under
+// "fields".
+func NewJSONLogSystem(w io.Writer) LogSystem {
+	return &jsonLogSystem{w: w, level: Levels.Debug}
+}
+
+func (j *jsonLogSystem) GetLogLevel() Level {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.level
+}
+
+func (j *jsonLogSystem) SetLogLevel(lvl Level) {
+	j.mu.Lock()
+	j.level = lvl
+	j.mu.Unlock()
+}
+
+func (j *jsonLogSystem) LogPrint(lvl Level, p []byte) error {
+	return j.LogPrintFields(lvl, p, nil)
+}
+
+func (j *jsonLogSystem) LogPrintFields(lvl Level, p []byte, fields []Field) error {
+	line := jsonLine(lvl, p, fields)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err := j.w.Write(line)
+	return err
+}
+
+// jsonLine hand-renders the JSON object so the sink doesn't need an
+// encoding/json round trip (and its allocations) on the hot path.
+func jsonLine(lvl Level, p []byte, fields []Field) []byte {
+	ts := time.Now().Format("2006-01-02T15:04:05.000000Z07:00")
+
+	var b []byte
+	b = append(b, `{"ts":`...)
+	b = append(b, jsonString(ts)...)
+	b = append(b, `,"level":`...)
+	b = append(b, jsonString(string(levelMapFmt[lvl]))...)
+	b = append(b, `,"msg":`...)
+	b = append(b, jsonString(string(p))...)
+	if len(fields) > 0 {
+		b = append(b, `,"fields":{`...)
+		for i, f := range fields {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, jsonString(f.Key)...)
+			b = append(b, ':')
+			b = append(b, jsonString(fmt.Sprint(f.Value))...)
+		}
+		b = append(b, '}')
+	}
+	b = append(b, "}\n"...)
+	return b
+}
+
+func jsonString(s string) []byte {
+	out := make([]byte, 0, len(s)+2)
+	out = append(out, '"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			out = append(out, '\\', byte(r))
+		case '\n':
+			out = append(out, '\\', 'n')
+		case '\r':
+			out = append(out, '\\', 'r')
+		case '\t':
+			out = append(out, '\\', 't')
+		default:
+			if r < 0x20 {
+				out = append(out, []byte(fmt.Sprintf(`\u%04x`, r))...)
+				continue
+			}
+			out = append(out, []byte(string(r))...)
+		}
+	}
+	out = append(out, '"')
+	return out
+}
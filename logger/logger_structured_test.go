@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFieldsDropsTrailingKey(t *testing.T) {
+	got := parseFields([]interface{}{"a", 1, "b", "two", "dangling"})
+	want := []Field{{Key: "a", Value: 1}, {Key: "b", Value: "two"}}
+
+	if len(got) != len(want) {
+		t.Fatalf("parseFields = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseFields[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAddFieldSpillsIntoExtra(t *testing.T) {
+	m := &logMessage{}
+	for i := 0; i < len(m.fieldsInline)+2; i++ {
+		m.addField(Field{Key: "k", Value: i})
+	}
+
+	fields := m.fields()
+	if len(fields) != len(m.fieldsInline)+2 {
+		t.Fatalf("fields() returned %d fields, want %d", len(fields), len(m.fieldsInline)+2)
+	}
+	if len(m.fieldsExtra) != 2 {
+		t.Errorf("fieldsExtra has %d entries, want 2", len(m.fieldsExtra))
+	}
+}
+
+// TestResetFieldsClearsReferences guards against the pooled logMessage
+// reuse path leaking a prior caller's field values: logMessages are
+// recycled forever through the fixed freeMessages pool, so resetFields
+// must zero every slot it used, not just truncate length.
+func TestResetFieldsClearsReferences(t *testing.T) {
+	m := &logMessage{}
+	for i := 0; i < len(m.fieldsInline)+2; i++ {
+		m.addField(Field{Key: "k", Value: new(int)})
+	}
+
+	m.resetFields()
+
+	for i, f := range m.fieldsInline {
+		if f != (Field{}) {
+			t.Errorf("fieldsInline[%d] = %v, want zero value", i, f)
+		}
+	}
+	if cap(m.fieldsExtra) > 0 {
+		for i, f := range m.fieldsExtra[:cap(m.fieldsExtra)] {
+			if f != (Field{}) {
+				t.Errorf("fieldsExtra backing slot %d = %v, want zero value", i, f)
+			}
+		}
+	}
+	if len(m.fields()) != 0 {
+		t.Errorf("fields() after reset = %v, want empty", m.fields())
+	}
+}
+
+func TestJSONLineIncludesTimestampLevelAndFields(t *testing.T) {
+	line := string(jsonLine(Levels.Info, []byte("hello"), []Field{{Key: "user", Value: "alice"}}))
+
+	for _, want := range []string{`"ts":"`, `"level":"[Info] "`, `"msg":"hello"`, `"fields":{"user":"alice"}`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("jsonLine() = %s, want substring %q", line, want)
+		}
+	}
+}
+
+func TestJSONStringEscapesControlCharacters(t *testing.T) {
+	got := string(jsonString("line1\nline2\ttab\"quote"))
+	want := `"line1\nline2\ttab\"quote"`
+	if got != want {
+		t.Errorf("jsonString = %s, want %s", got, want)
+	}
+}
+
+func TestLoggerWithMergesFields(t *testing.T) {
+	base := With("service", "api")
+	child := base.With("request_id", "123")
+
+	if len(base.fields) != 1 {
+		t.Fatalf("base.With mutated the original logger: fields = %v", base.fields)
+	}
+	if len(child.fields) != 2 {
+		t.Fatalf("child.fields = %v, want 2 entries", child.fields)
+	}
+}
@@ -0,0 +1,65 @@
+package logger
+
+import "testing"
+
+func TestFileVerbosityFallsBackToGlobal(t *testing.T) {
+	SetVerbosity(0)
+	defer SetVerbosity(0)
+	SetVModule("")
+	defer SetVModule("")
+
+	SetVerbosity(2)
+	if got := fileVerbosity("server.go"); got != 2 {
+		t.Errorf("fileVerbosity with no vmodule rules = %d, want 2", got)
+	}
+}
+
+func TestFileVerbosityVModuleOverride(t *testing.T) {
+	SetVerbosity(0)
+	defer SetVerbosity(0)
+	defer SetVModule("")
+
+	if err := SetVModule("server*=3,auth=5"); err != nil {
+		t.Fatalf("SetVModule: %v", err)
+	}
+
+	if got := fileVerbosity("server_http.go"); got != 3 {
+		t.Errorf("fileVerbosity(server_http.go) = %d, want 3", got)
+	}
+	if got := fileVerbosity("auth"); got != 5 {
+		t.Errorf("fileVerbosity(auth) = %d, want 5", got)
+	}
+	if got := fileVerbosity("unrelated.go"); got != 0 {
+		t.Errorf("fileVerbosity(unrelated.go) = %d, want global threshold 0", got)
+	}
+}
+
+func TestVRespectsSetVerbosityAfterCaching(t *testing.T) {
+	SetVerbosity(0)
+	defer SetVerbosity(0)
+
+	// Both checks call V from the same call site inside v1, so the first
+	// call primes vCache for that PC at the current (disabled) threshold.
+	v1 := func() Verbose { return V(1) }
+
+	if v := v1(); v {
+		t.Fatal("V(1) enabled at verbosity 0, want disabled")
+	}
+
+	SetVerbosity(5)
+
+	if v := v1(); !v {
+		t.Error("V(1) still disabled after SetVerbosity(5): cache wasn't invalidated")
+	}
+}
+
+func TestSetVModuleRejectsInvalidSpec(t *testing.T) {
+	defer SetVModule("")
+
+	if err := SetVModule("nodelimiter"); err != ErrInvalidVModule {
+		t.Errorf("SetVModule(\"nodelimiter\") = %v, want ErrInvalidVModule", err)
+	}
+	if err := SetVModule("pattern=notanumber"); err != ErrInvalidVModule {
+		t.Errorf("SetVModule(\"pattern=notanumber\") = %v, want ErrInvalidVModule", err)
+	}
+}
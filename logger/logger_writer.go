@@ -2,9 +2,11 @@ package logger
 
 import (
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -29,7 +31,14 @@ const (
 // container for a pending log message
 type logMessage struct {
 	bytes.Buffer
-	level C.int
+	level Level
+
+	// structured fields attached via Infow/With. Up to len(fieldsInline) are
+	// kept inline to avoid an allocation for the common case; anything
+	// beyond that spills into fieldsExtra.
+	fieldsLen    int
+	fieldsInline [8]Field
+	fieldsExtra  []Field
 }
 
 var (
@@ -69,17 +78,41 @@ var (
 		Levels.Debug:  []byte("[Debug] "),
 	}
 
-	customSock      net.Conn = nil
 	shuttingDownMux sync.RWMutex
 	shuttingDown    bool
 	wg              sync.WaitGroup
 )
 
 // When called, this will switch over to writting log messages to the defined socket.
-func SetCustomSocket(address, network string) (err error) {
-	customSock, err = net.Dial(network, address)
+func SetCustomSocket(address, network string, opts ...CustomSocketOption) (err error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return err
+	}
 
-	return err
+	stream := network != "udp" && network != "udp4" && network != "udp6"
+	sink := newCustomSocketLogSystem(conn, stream, network, address, nil, opts...)
+	customSocketSink = sink
+	AddLogSystem(sink)
+
+	return nil
+}
+
+// SetCustomSocketTLS adds a sink that writes log messages to a remote
+// syslog collector over TLS, per RFC 5425, alongside any sinks already
+// registered. Like the plain TCP path it uses
+// RFC 6587 octet-counting to frame records.
+func SetCustomSocketTLS(address string, config *tls.Config, opts ...CustomSocketOption) (err error) {
+	conn, err := tls.Dial("tcp", address, config)
+	if err != nil {
+		return err
+	}
+
+	sink := newCustomSocketLogSystem(conn, true, "tcp", address, config, opts...)
+	customSocketSink = sink
+	AddLogSystem(sink)
+
+	return nil
 }
 
 // SetLogName sets the indentifier used by syslog for this program
@@ -88,6 +121,7 @@ func SetLogName(p string) (err error) {
 		C.free(unsafe.Pointer(logName))
 	}
 	logName = C.CString(p)
+	logNameStr = p
 	_, err = C.openlog(logName, C.LOG_NDELAY|C.LOG_NOWAIT|C.LOG_PID, C.LOG_USER)
 	if err != nil {
 		atomic.AddUint64(&errCount, 1)
@@ -109,8 +143,10 @@ func freeMsg(msg *logMessage) (err error) {
 }
 
 // queueMsgDirect adds a message to the pending messages channel. It will drop the
-// message and return an error if the channel is full.
-func queueMsgDirect(logEntry string) (err error) {
+// message and return an error if the channel is full. Any fields are carried
+// along on the message so each registered LogSystem can render them in its
+// own native format.
+func queueMsgDirect(lvl Level, logEntry string, fields ...Field) (err error) {
 
 	shuttingDownMux.RLock()
 	defer shuttingDownMux.RUnlock()
@@ -126,6 +162,11 @@ func queueMsgDirect(logEntry string) (err error) {
 		case msg = <-freeMessages:
 			defer func() {
 				if err != nil {
+					// same pool-reuse hazard as logWriter's return path:
+					// clear out this attempt's bytes/fields before the
+					// message goes back to the pool for someone else.
+					msg.Reset()
+					msg.resetFields()
 					freeMsg(msg)
 				}
 			}()
@@ -135,15 +176,14 @@ func queueMsgDirect(logEntry string) (err error) {
 			return
 		}
 
-		// render the message: level prefix, message body, C null terminator
-		msg.level = levelSysLog[Levels.Debug]
-		if msg.Write([]byte(logEntry)); err != nil {
+		// render the message: level, message body
+		msg.level = lvl
+		if _, err = msg.Write([]byte(logEntry)); err != nil {
 			atomic.AddUint64(&errCount, 1)
 			return
 		}
-		if msg.WriteByte(0); err != nil {
-			atomic.AddUint64(&errCount, 1)
-			return
+		for _, f := range fields {
+			msg.addField(f)
 		}
 
 		// queue the message
@@ -166,47 +206,59 @@ func queueMsgDirect(logEntry string) (err error) {
 // message and return an error if the channel is full.
 func queueMsg(lvl Level, prefix, format string, v ...interface{}) (err error) {
 	logMsg := fmt.Sprintf("%s %s %s", levelMapFmt[lvl], prefix, fmt.Sprintf(format, v...))
-	return queueMsgDirect(logMsg)
+	return queueMsgDirect(lvl, logMsg)
 }
 
-// write a message to syslog. This is a concrete, blocking event.
+// write a message to syslog. This is a concrete, blocking event. The C
+// string passed to csyslog needs its own null terminator, which is an
+// implementation detail of this one cgo call, not something the rest of
+// the pipeline (or any other LogSystem) should ever see on msg.Bytes().
 func write(msg *logMessage) (err error) {
+	msg.WriteByte(0)
 	start := (*C.char)(unsafe.Pointer(&msg.Bytes()[0]))
-	if _, err = C.csyslog(C.LOG_USER|msg.level, start); err != nil {
+	if _, err = C.csyslog(C.LOG_USER|levelSysLog[msg.level], start); err != nil {
 		atomic.AddUint64(&errCount, 1)
 	}
 	return
 }
 
-// write a message to a pre-defined custom socket. This is a concrete, blocking event.
-// Writes out using the syslog rfc5424 format.
-func writeCustomSocket(msg *logMessage) (err error) {
-	if _, err = customSock.Write(bytes.Join([][]byte{[]byte(fmt.Sprintf("<%d>", C.LOG_USER|msg.level)),
-		msg.Bytes()}, []byte(""))); err != nil {
-		atomic.AddUint64(&errCount, 1)
-	}
-	return
+// renderRecord renders msg into a full RFC 5424 record, without writing it
+// anywhere. Shared by the unbatched write path and the batching/compression
+// path, which needs the rendered record before it can frame and compress it.
+func renderRecord(msg *logMessage) []byte {
+	pri := int(C.LOG_USER | levelSysLog[msg.level])
+	return msgFormatter.Format(pri, logNameStr, os.Getpid(), msgID, msg.fields(), msg.Bytes())
+}
+
+// write a message to a custom socket. This is a concrete, blocking event.
+// Writes out using full RFC 5424 framing, with RFC 6587 octet-counting on
+// stream transports.
+func writeCustomSocket(conn net.Conn, stream bool, msg *logMessage) (err error) {
+	return writeFramed(conn, stream, renderRecord(msg))
 }
 
-// logWriter will write out messages to syslog. It may block if something breaks
-// within the syslog call.
+// logWriter will fan out messages to every registered LogSystem. It may
+// block if something breaks within one of the sinks.
 func logWriter() {
 	for msg := range messages {
-		if customSock == nil {
-			write(msg)
-		} else {
-			writeCustomSocket(msg)
-		}
+		dispatch(msg)
 
 		if msg.Cap() > MaxFreeMsgSize {
 			*msg = logMessage{}
 		} else {
 			msg.Reset()
+			msg.resetFields()
 		}
 		freeMsg(msg)
 	}
-	if customSock != nil {
-		customSock.Close()
+
+	// give any sink that buffers internally (e.g. a batching custom socket)
+	// a chance to push out its last partial batch while its connection is
+	// still open
+	flushLogSystems()
+
+	if customSocketSink != nil {
+		customSocketSink.Close()
 	}
 }
 
@@ -218,6 +270,8 @@ func init() {
 		}
 	}
 
+	AddLogSystem(newSyslogLogSystem())
+
 	wg.Add(1)
 	go func() {
 		logWriter()
@@ -238,5 +292,4 @@ func drainTheQueue() {
 
 	// wait for logwriter to close
 	wg.Wait()
-
 }